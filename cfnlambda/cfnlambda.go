@@ -0,0 +1,104 @@
+// Package cfnlambda wires a cfncustomresource.ReqHandler into the AWS
+// Lambda runtime, so that a custom resource provider doesn't need to
+// hand-roll the glue between github.com/aws/aws-lambda-go/lambda and
+// this module.
+package cfnlambda
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+
+	cfncustomresource "github.com/MinneapolisStarTribune/cfn-custom-resource-go"
+)
+
+// DeadlineSafetyMargin is subtracted from the Lambda invocation's
+// deadline when deriving Request.Ctx, so a handler that is still
+// running when the real deadline approaches has time to notice and
+// send a FailureResponse before Lambda freezes or kills the process
+// out from under it.
+const DeadlineSafetyMargin = 5 * time.Second
+
+// Start begins the Lambda runtime and invokes handler for every
+// CloudFormation custom resource event it receives. It is the
+// Lambda-flavored equivalent of the hand-rolled
+//
+//	for {
+//		r := &cfncustomresource.Request{}
+//		r.Try(handler)
+//	}
+//
+// loop a handler would otherwise be driven by: it unmarshals the incoming
+// event into a Request, populates Request.Ctx with a deadline derived
+// from the Lambda invocation's own deadline minus DeadlineSafetyMargin,
+// and calls Request.Try. If that deadline is reached before handler
+// returns, Start sends a FailureResponse on handler's behalf so that
+// CloudFormation doesn't have to wait out the full hour it otherwise
+// allows before rolling back.
+func Start(handler cfncustomresource.ReqHandler) {
+	lambda.StartHandler(runner{handler})
+}
+
+type runner struct {
+	handler cfncustomresource.ReqHandler
+}
+
+// Invoke implements lambda.Handler.
+func (r runner) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	req := &cfncustomresource.Request{}
+	if err := json.Unmarshal(payload, req); err != nil {
+		return nil, fmt.Errorf("cfnlambda: could not unmarshal event: %w", err)
+	}
+
+	// lambdacontext.FromContext surfaces the invocation's AWS request
+	// id, which is worth threading into our own errors so a timeout can
+	// be correlated back to the matching Lambda invocation in
+	// CloudWatch logs. ok is false if we weren't invoked by the Lambda
+	// runtime at all (e.g. a handler called directly in a test).
+	var awsRequestId string
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		awsRequestId = lc.AwsRequestID
+	}
+
+	deadlineCtx := ctx
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		deadlineCtx, cancel = context.WithDeadline(ctx, deadline.Add(-DeadlineSafetyMargin))
+		defer cancel()
+	}
+	req.Ctx = deadlineCtx
+
+	done := make(chan error, 1)
+	go func() { done <- req.Try(r.handler) }()
+
+	select {
+	case err := <-done:
+		return nil, err
+	case <-deadlineCtx.Done():
+		// The handler goroutine above is still running and may still be
+		// reading or writing req, so we can't safely touch req from
+		// here -- instead build an independent Request from the fields
+		// that are fixed once the event is unmarshaled, and send the
+		// timeout failure on that. We deliberately use ctx rather than
+		// deadlineCtx (which just fired): ctx still has roughly
+		// DeadlineSafetyMargin left, which is exactly the time this
+		// response needs to actually reach CloudFormation before Lambda
+		// kills the process.
+		timeoutReq := &cfncustomresource.Request{
+			RequestType:       req.RequestType,
+			ResponseURL:       req.ResponseURL,
+			StackId:           req.StackId,
+			RequestId:         req.RequestId,
+			LogicalResourceId: req.LogicalResourceId,
+			Ctx:               ctx,
+		}
+		if ferr := timeoutReq.FailureResponse("timed out before Lambda's deadline").Send(); ferr != nil {
+			return nil, fmt.Errorf("cfnlambda: handler did not return before deadline (aws request id %s), and failure response could not be sent: %w", awsRequestId, ferr)
+		}
+		return nil, fmt.Errorf("cfnlambda: handler did not return before deadline (aws request id %s)", awsRequestId)
+	}
+}
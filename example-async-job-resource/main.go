@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+
+	cfncustomresource "github.com/MinneapolisStarTribune/cfn-custom-resource-go"
+	"github.com/MinneapolisStarTribune/cfn-custom-resource-go/cfnlambda"
+)
+
+// AsyncJobResource models a resource whose creation kicks off a job
+// that takes longer than a single Lambda invocation can wait out. It
+// polls the job via InProgressResponse/CallbackContext until the job
+// reports done, then sends the real terminal response.
+//
+// This is a classic (non-cfn-registry) custom resource, so
+// CloudFormation has no built-in way to re-invoke us -- that's the
+// delayedRescheduler's job below.
+func AsyncJobResource(r *cfncustomresource.Request) error {
+	if r.RequestType == "Delete" {
+		// assume the job can't meaningfully be canceled once started
+		return r.DeletedResponse().Send()
+	}
+
+	type callback struct {
+		JobId     string
+		PollCount int
+	}
+	var cb callback
+	if len(r.CallbackContext) > 0 {
+		if err := json.Unmarshal(r.CallbackContext, &cb); err != nil {
+			return err
+		}
+	} else {
+		type Props struct {
+			JobInput string
+		}
+		props := &Props{}
+		if err := json.Unmarshal(r.ResourceProperties, props); err != nil {
+			return err
+		}
+		jobId, err := startJob(r.Ctx, props.JobInput)
+		if err != nil {
+			return err
+		}
+		cb.JobId = jobId
+	}
+
+	done, result, err := pollJob(r.Ctx, cb.JobId, cb.PollCount)
+	if err != nil {
+		return err
+	}
+	if !done {
+		cb.PollCount++
+		return r.InProgressResponse(cb, 30).WithRescheduler(delayedRescheduler{}).Send()
+	}
+
+	type Attrs struct {
+		Result string
+	}
+	attrs := &Attrs{Result: result}
+	if r.RequestType == "Create" {
+		phid := r.RandomPhysicalId(rand.New(rand.NewSource(time.Now().UnixNano())))
+		return r.CreatedResponse(phid, attrs).Send()
+	}
+	return r.UpdatedResponse(attrs).Send()
+}
+
+// delayedRescheduler stands in for whatever redelivery mechanism a real
+// deployment would use -- typically a StepFunctions Wait state or an
+// SQS queue with a delivery delay feeding back into this same Lambda
+// function. Here it just re-invokes the handler in-process after the
+// requested delay, which is enough to demonstrate the API but is not
+// how you'd want this to behave in Lambda, where the process can be
+// frozen or recycled between invocations.
+type delayedRescheduler struct{}
+
+func (delayedRescheduler) Reschedule(ctx context.Context, r *cfncustomresource.Request, callbackContext json.RawMessage, delaySeconds int) error {
+	// Build a fresh Request rather than reusing r: r.responseSent is
+	// set to true by the IN_PROGRESS send that triggered this call as
+	// soon as Reschedule returns, and a real redelivery (via SQS/StepFunctions) would hand the
+	// handler a brand new Request anyway. Reusing r would make a later
+	// failure in this chain hit Try's "response already sent" branch
+	// and never send a FailureResponse, silently stalling the stack.
+	next := &cfncustomresource.Request{
+		RequestType:           r.RequestType,
+		ResponseURL:           r.ResponseURL,
+		StackId:               r.StackId,
+		RequestId:             r.RequestId,
+		ResourceType:          r.ResourceType,
+		LogicalResourceId:     r.LogicalResourceId,
+		ResourceProperties:    r.ResourceProperties,
+		PhysicalResourceId:    r.PhysicalResourceId,
+		OldResourceProperties: r.OldResourceProperties,
+		CallbackContext:       callbackContext,
+	}
+	time.AfterFunc(time.Duration(delaySeconds)*time.Second, func() {
+		next.Try(AsyncJobResource)
+	})
+	return nil
+}
+
+// startJob and pollJob stand in for whatever the real async operation
+// is -- a domain transfer, an ACM validation, a batch job, etc.
+func startJob(ctx context.Context, input string) (jobId string, err error) {
+	return fmt.Sprintf("job-%s", input), nil
+}
+
+// pollsUntilDone is how many polls this stand-in job answers "not
+// done" before the next poll reports it done, so the example actually exercises InProgressResponse and
+// delayedRescheduler across several invocations instead of finishing
+// on the first one.
+const pollsUntilDone = 3
+
+func pollJob(ctx context.Context, jobId string, pollCount int) (done bool, result string, err error) {
+	if pollCount < pollsUntilDone {
+		return false, "", nil
+	}
+	return true, "ok", nil
+}
+
+func main() {
+	cfnlambda.Start(AsyncJobResource)
+}
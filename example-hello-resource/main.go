@@ -4,10 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
-	"os"
 	"time"
 
 	cfncustomresource "github.com/MinneapolisStarTribune/cfn-custom-resource-go"
+	"github.com/MinneapolisStarTribune/cfn-custom-resource-go/cfnlambda"
 )
 
 func GreeterResource(r *cfncustomresource.Request) error {
@@ -42,10 +42,5 @@ func GreeterResource(r *cfncustomresource.Request) error {
 }
 
 func main() {
-	for {
-		r := &cfncustomresource.Request{} // from your request handler
-		if err := r.Try(GreeterResource); err != nil {
-			fmt.Fprintf(os.Stderr, "%v\n", err)
-		}
-	}
+	cfnlambda.Start(GreeterResource)
 }
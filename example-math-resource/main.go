@@ -7,6 +7,7 @@ import (
 	"time"
 
 	cfncustomresource "github.com/MinneapolisStarTribune/cfn-custom-resource-go"
+	"github.com/MinneapolisStarTribune/cfn-custom-resource-go/cfnlambda"
 )
 
 // MathResource demonstrates a generic resource that can customize its
@@ -67,3 +68,7 @@ func AdderResource(r *cfncustomresource.Request) error {
 	}
 	panic("invalid request type")
 }
+
+func main() {
+	cfnlambda.Start(MathResource)
+}
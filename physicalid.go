@@ -0,0 +1,38 @@
+package cfncustomresource
+
+import "hash/fnv"
+
+// base62alphabet is used by DeterministicPhysicalId to keep the encoded
+// hash short and safe to embed in a physical id.
+const base62alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+func base62encode(n uint64) string {
+	if n == 0 {
+		return "0"
+	}
+	var buf [11]byte // enough digits for a 64-bit value in base 62
+	i := len(buf)
+	for n > 0 {
+		i--
+		buf[i] = base62alphabet[n%62]
+		n /= 62
+	}
+	return string(buf[i:])
+}
+
+// DeterministicPhysicalId returns a stable physical id derived from the
+// FNV-1a hash of StackId and LogicalResourceId, base62-encoded and
+// prefixed with the logical id. Because it depends only on fields that
+// are identical across every request CloudFormation sends for a given
+// resource, a handler can compute the same value at any point in that
+// resource's lifecycle -- most usefully, to recognize and clean up a
+// resource that was left behind by a Create that failed partway
+// through (see Try, which uses this to populate the FailureResponse to
+// a failed Create).
+func (req *Request) DeterministicPhysicalId() string {
+	h := fnv.New64a()
+	h.Write([]byte(req.StackId))
+	h.Write([]byte{0}) // separator, in case StackId could otherwise run into LogicalResourceId
+	h.Write([]byte(req.LogicalResourceId))
+	return req.LogicalResourceId + "-" + base62encode(h.Sum64())
+}
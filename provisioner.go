@@ -0,0 +1,86 @@
+package cfncustomresource
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// A Provisioner implements the three lifecycle operations CloudFormation
+// drives a custom resource through, with its resource properties
+// already decoded into P. It is a higher-level alternative to writing a
+// ReqHandler by hand and switching on RequestType yourself; use
+// HandleProvisioner to adapt one into a ReqHandler.
+type Provisioner[P any] interface {
+	// Create provisions a new resource from props and returns its
+	// physical id plus any attributes that should be available via
+	// !GetAtt. An error results in a FailureResponse.
+	Create(ctx context.Context, props P) (physicalId string, attrs interface{}, err error)
+
+	// Update applies newProps to the resource identified by
+	// physicalId, which was created with oldProps. Returning the same
+	// physicalId it was given tells CloudFormation the resource was
+	// updated in place; returning a different one tells CloudFormation
+	// a replacement resource was created (which will trigger a Delete
+	// of physicalId once the stack operation completes).
+	Update(ctx context.Context, physicalId string, oldProps, newProps P) (newPhysicalId string, attrs interface{}, err error)
+
+	// Delete removes the resource identified by physicalId.
+	Delete(ctx context.Context, physicalId string, props P) error
+}
+
+// HandleProvisioner adapts a Provisioner into a ReqHandler, decoding
+// ResourceProperties (and, for updates, OldResourceProperties) into P
+// before dispatching to the matching Provisioner method, and
+// translating its return values into the appropriate Response. The raw
+// ReqHandler API is left in place for handlers that need more control
+// than this adapter offers.
+//
+//	type WidgetProps struct {
+//		Size int
+//	}
+//
+//	func main() {
+//		cfnlambda.Start(cfncustomresource.HandleProvisioner[WidgetProps](&WidgetProvisioner{}))
+//	}
+func HandleProvisioner[P any](p Provisioner[P]) ReqHandler {
+	return func(r *Request) error {
+		switch r.RequestType {
+		case "Create":
+			var props P
+			if err := json.Unmarshal(r.ResourceProperties, &props); err != nil {
+				return err
+			}
+			phid, attrs, err := p.Create(r.Ctx, props)
+			if err != nil {
+				return err
+			}
+			return r.CreatedResponse(phid, attrs).Send()
+		case "Update":
+			var oldProps, newProps P
+			if err := json.Unmarshal(r.OldResourceProperties, &oldProps); err != nil {
+				return err
+			}
+			if err := json.Unmarshal(r.ResourceProperties, &newProps); err != nil {
+				return err
+			}
+			newPhid, attrs, err := p.Update(r.Ctx, r.PhysicalResourceId, oldProps, newProps)
+			if err != nil {
+				return err
+			}
+			if newPhid == r.PhysicalResourceId {
+				return r.UpdatedResponse(attrs).Send()
+			}
+			return r.ReplacedResponse(newPhid, attrs).Send()
+		case "Delete":
+			var props P
+			if err := json.Unmarshal(r.ResourceProperties, &props); err != nil {
+				return err
+			}
+			if err := p.Delete(r.Ctx, r.PhysicalResourceId, props); err != nil {
+				return err
+			}
+			return r.DeletedResponse().Send()
+		}
+		panic("invalid request type")
+	}
+}
@@ -28,6 +28,13 @@ type Request struct {
 	// the properties of the resource prior to this update request.
 	OldResourceProperties json.RawMessage `json:",omitempty"`
 
+	// CallbackContext carries opaque state between invocations of a
+	// long-running resource that returns an InProgressResponse. It is
+	// populated from the incoming event when CloudFormation (or a
+	// Rescheduler) re-invokes the handler as part of such a chain, and
+	// is otherwise empty.
+	CallbackContext json.RawMessage `json:",omitempty"`
+
 	// Ctx is an optional way to limit runtime for each request
 	Ctx context.Context `json:"-"`
 
@@ -74,6 +81,13 @@ func (req *Request) RandomPhysicalId(src *rand.Rand) string {
 // wasn't able to be completed. In most cases, this will result in a
 // stack rollback. A reason must be provided; err.Error() is a good
 // place to start.
+//
+// When called by Try after a Create handler returns an error without
+// having sent a response, the PhysicalResourceId on the
+// response will already have been set to DeterministicPhysicalId, so
+// that the Delete CloudFormation sends as part of the rollback arrives
+// with an id the handler can recognize and clean up idempotently,
+// rather than the placeholder CloudFormation would otherwise supply.
 func (req *Request) FailureResponse(reason string) *Response {
 	fmt.Fprintln(os.Stderr, "returning a failure response", reason)
 	resp := baseResponse(req)
@@ -143,6 +157,31 @@ func (req *Request) DeletedResponse() *Response {
 	return baseResponse(req)
 }
 
+// An InProgressResponse tells the caller that the operation is still
+// running and needs to be retried, rather than telling CloudFormation
+// anything final. callbackContext is opaque state that will be
+// available as Request.CallbackContext on the next invocation;
+// callbackDelaySeconds is how long to wait before that invocation, at
+// minimum.
+//
+// Send never reports an IN_PROGRESS status to CloudFormation, whichever
+// way the resource is deployed: it uses the Rescheduler attached via
+// Response.WithRescheduler to arrange redelivery instead of contacting
+// CloudFormation at all, and returns an error if no Rescheduler is
+// attached. From CloudFormation's perspective, the original request is
+// still outstanding.
+func (req *Request) InProgressResponse(callbackContext interface{}, callbackDelaySeconds int) *Response {
+	raw, err := json.Marshal(callbackContext)
+	if err != nil {
+		panic(fmt.Sprintf("in-progress response with unmarshalable callback context: %v", err))
+	}
+	resp := baseResponse(req)
+	resp.Status = "IN_PROGRESS"
+	resp.CallbackContext = raw
+	resp.CallbackDelaySeconds = callbackDelaySeconds
+	return resp
+}
+
 // A ReqHandler is a func that processes a single Request and returns
 // an error or nil.
 type ReqHandler func(*Request) error
@@ -159,9 +198,22 @@ func (req *Request) Try(f ReqHandler) (err error) {
 			if req.responseSent {
 				// if a response was already created, just capture and return
 				err = fmt.Errorf("received error but response already sent: %w", err)
-			} else if ferr := req.FailureResponse(err.Error()).Send(); ferr != nil {
-				// something else is wrong, bail out to the runtime
-				panic(fmt.Errorf("cannot send error response in error handler: %w", ferr))
+			} else {
+				if req.RequestType == "Create" && req.PhysicalResourceId == "" {
+					// f failed without sending a response, so CloudFormation
+					// has no physical id on file for this resource. This also
+					// covers a CreatedResponse whose Send failed: the id f
+					// passed to it is not carried over. Without one,
+					// the follow-up Delete it sends arrives with a placeholder
+					// id like "resource-failed-to-create", leaving any side
+					// effect f already caused orphaned. Fill in a deterministic
+					// id so f can recognize and clean it up on that Delete.
+					req.PhysicalResourceId = req.DeterministicPhysicalId()
+				}
+				if ferr := req.FailureResponse(err.Error()).Send(); ferr != nil {
+					// something else is wrong, bail out to the runtime
+					panic(fmt.Errorf("cannot send error response in error handler: %w", ferr))
+				}
 			}
 		}
 	}()
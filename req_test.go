@@ -0,0 +1,100 @@
+package cfncustomresource
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDeterministicPhysicalId(t *testing.T) {
+	r1 := &Request{StackId: "stack-a", LogicalResourceId: "MyResource"}
+	r2 := &Request{StackId: "stack-a", LogicalResourceId: "MyResource"}
+	id1, id2 := r1.DeterministicPhysicalId(), r2.DeterministicPhysicalId()
+	if id1 != id2 {
+		t.Fatalf("expected the same StackId+LogicalResourceId to produce the same id, got %q and %q", id1, id2)
+	}
+	if !strings.HasPrefix(id1, "MyResource-") {
+		t.Fatalf("expected id to be prefixed with the logical resource id, got %q", id1)
+	}
+
+	r3 := &Request{StackId: "stack-b", LogicalResourceId: "MyResource"}
+	if id3 := r3.DeterministicPhysicalId(); id3 == id1 {
+		t.Fatalf("expected a different StackId to produce a different id, got %q for both", id3)
+	}
+}
+
+// TestTryCreateFailureUsesDeterministicPhysicalId verifies the pattern
+// DeterministicPhysicalId exists for: a Create handler that fails after
+// already causing a side effect should still hand CloudFormation a
+// PhysicalResourceId it can recognize on the Delete that follows.
+func TestTryCreateFailureUsesDeterministicPhysicalId(t *testing.T) {
+	var received Response
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("could not decode callback payload: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	req := &Request{
+		RequestType:       "Create",
+		ResponseURL:       srv.URL,
+		StackId:           "stack-a",
+		LogicalResourceId: "MyResource",
+	}
+	wantId := req.DeterministicPhysicalId()
+
+	handlerErr := errors.New("side effect already happened, boom")
+	if err := req.Try(func(r *Request) error { return handlerErr }); !errors.Is(err, handlerErr) {
+		t.Fatalf("expected Try to return the handler's error, got %v", err)
+	}
+
+	if received.Status != "FAILED" {
+		t.Fatalf("expected a FAILED response, got %q", received.Status)
+	}
+	if received.PhysicalResourceId != wantId {
+		t.Fatalf("expected PhysicalResourceId %q, got %q", wantId, received.PhysicalResourceId)
+	}
+}
+
+// TestTryCreateFailureAfterCreatedResponsePreservesPhysicalId verifies
+// that Try's deterministic-id fallback only kicks in when the handler
+// has not sent a response -- if it already sent a CreatedResponse, that
+// physical id must be preserved rather than overwritten.
+func TestTryCreateFailureAfterCreatedResponsePreservesPhysicalId(t *testing.T) {
+	var received Response
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("could not decode callback payload: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	req := &Request{
+		RequestType:       "Create",
+		ResponseURL:       srv.URL,
+		StackId:           "stack-a",
+		LogicalResourceId: "MyResource",
+	}
+
+	err := req.Try(func(r *Request) error {
+		if sendErr := r.CreatedResponse("already-real-physical-id", nil).Send(); sendErr != nil {
+			return sendErr
+		}
+		return errors.New("reported success, but Try should only report back on sent responses")
+	})
+	// a response was already sent, so Try should report that rather
+	// than sending a second, conflicting one.
+	if err == nil {
+		t.Fatal("expected Try to return an error when the handler errors after already sending a response")
+	}
+	if received.Status != "SUCCESS" {
+		t.Fatalf("expected the already-sent SUCCESS response to stand, got %q", received.Status)
+	}
+	if received.PhysicalResourceId != "already-real-physical-id" {
+		t.Fatalf("expected the handler's own physical id to be preserved, got %q", received.PhysicalResourceId)
+	}
+}
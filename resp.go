@@ -5,11 +5,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 )
 
 // Response represents the result of processing a Request.
-// At the moment, responses must be a maximum of 4096 bytes.
+// At the moment, responses must be a maximum of 4096 bytes, unless
+// WithLargePayloadBucket is used to off-load Data to S3.
 //
 // The Resopnse type is of course usable directly, but the most ergonomic
 // way to use it is to chain methods together. For instance:
@@ -64,10 +66,91 @@ type Response struct {
 	NoEcho             bool        `json:",omitempty"`
 	Data               interface{} `json:",omitempty"`
 
+	// CallbackContext and CallbackDelaySeconds are only set by
+	// InProgressResponse; see Request.InProgressResponse.
+	CallbackContext      json.RawMessage `json:",omitempty"`
+	CallbackDelaySeconds int             `json:",omitempty"`
+
 	Ctx context.Context `json:"-"`
 
+	req     *Request
 	respurl string
 	sent    *bool
+
+	largePayloadBucket    string
+	largePayloadKeyPrefix string
+	largePayloadUploader  S3Uploader
+
+	rescheduler Rescheduler
+}
+
+// An S3Uploader is the minimal interface Response needs in order to
+// off-load a payload that is too large for CloudFormation's direct
+// callback; see WithLargePayloadBucket. It is satisfied by a small
+// wrapper around *s3.Client from aws-sdk-go-v2, which this module does
+// not depend on directly so that using this feature doesn't force that
+// dependency on callers who don't need it.
+type S3Uploader interface {
+	// PutObject uploads body to bucket under key, returning the S3
+	// object version id if the bucket is versioned.
+	PutObject(ctx context.Context, bucket, key string, body []byte) (versionId string, err error)
+}
+
+// An s3DataPointer replaces Data in the callback payload when
+// WithLargePayloadBucket has offloaded it to S3. Consuming stacks are
+// expected to dereference it through a companion "S3JSON" custom
+// resource that fetches Bucket/Key (and, if set, VersionId) and
+// returns its contents as that resource's own attributes.
+type s3DataPointer struct {
+	Bucket    string
+	Key       string
+	VersionId string `json:",omitempty"`
+}
+
+// WithLargePayloadBucket configures resp so that, if its marshalled
+// body would otherwise exceed CloudFormation's 4096 byte callback
+// limit, Send uploads the full Data value to bucket via uploader and
+// replaces Data in the callback payload with a small pointer object
+// (see s3DataPointer) instead of failing outright. The object key is
+// keyPrefix joined with the request's StackId, LogicalResourceId, and
+// RequestId, so repeated responses for the same request overwrite the
+// same key rather than accumulating garbage.
+//
+// This can be chained for convenience, such as:
+//
+//	return r.CreatedResponse(phid, bigAttrs).
+//		WithLargePayloadBucket("my-bucket", "cfn-payloads/", uploader).
+//		Send()
+func (resp *Response) WithLargePayloadBucket(bucket, keyPrefix string, uploader S3Uploader) *Response {
+	resp.largePayloadBucket = bucket
+	resp.largePayloadKeyPrefix = keyPrefix
+	resp.largePayloadUploader = uploader
+	return resp
+}
+
+// A Rescheduler arranges for a request to be delivered again later, for
+// providers deploying via classic (non-cfn-registry) custom resources,
+// where CloudFormation itself has no way to re-invoke the handler after
+// an InProgressResponse. A Rescheduler is typically backed by something
+// like a StepFunctions Wait state or an SQS delay queue that re-runs
+// the handler against the same event.
+type Rescheduler interface {
+	// Reschedule arranges for req to be redelivered, with
+	// CallbackContext set to callbackContext, no sooner than
+	// delaySeconds from now.
+	Reschedule(ctx context.Context, req *Request, callbackContext json.RawMessage, delaySeconds int) error
+}
+
+// WithRescheduler attaches a Rescheduler to resp, so that Send can
+// arrange redelivery of an InProgressResponse for custom resources that
+// don't go through the CloudFormation resource provider registry. It
+// has no effect on a Response that isn't in the "IN_PROGRESS" status,
+// but an IN_PROGRESS response sent without one is an error: with
+// nothing to re-invoke the handler, the request would otherwise hang
+// until CloudFormation gives up on its own after an hour.
+func (resp *Response) WithRescheduler(r Rescheduler) *Response {
+	resp.rescheduler = r
+	return resp
 }
 
 func baseResponse(req *Request) *Response {
@@ -83,6 +166,7 @@ func baseResponse(req *Request) *Response {
 		StackId:            req.StackId,            // must be identical
 		RequestId:          req.RequestId,          // must be identical
 		LogicalResourceId:  req.LogicalResourceId,  // must be identical
+		req:                req,                    // used internally
 		respurl:            req.ResponseURL,        // used internally
 		sent:               &req.responseSent,      // used internally
 		Ctx:                req.Ctx,                // used internally
@@ -115,7 +199,14 @@ func (resp *Response) Sensitive() *Response {
 // Send encodes the Response as a JSON payload and POSTs it to the URL
 // provided by CloudFormation in the Request. Note that the response
 // payload must be no more than 4096 bytes (per documentation in 2022)
-// and so any larger payload will be rejected.
+// and so any larger payload will be rejected, unless
+// WithLargePayloadBucket was used to configure an S3 fallback.
+//
+// A network error or 5xx response is retried according to
+// retryPolicy (see SetRetryPolicy); a 4xx response is returned
+// immediately, since it indicates a malformed payload that retrying
+// cannot fix. The retry loop respects Ctx, so a Lambda-deadline-driven
+// cancellation still short-circuits it.
 //
 // This method is intended to be chained, for example:
 //
@@ -123,25 +214,94 @@ func (resp *Response) Sensitive() *Response {
 //		return r.FailureResponse("not implemented yet").Send()
 //	}
 func (resp *Response) Send() error {
+	if resp.Status == "IN_PROGRESS" {
+		// the classic ResponseURL protocol this package otherwise speaks
+		// has no way to tell CloudFormation "come back later", so we
+		// never contact it here -- from CloudFormation's perspective the
+		// original request is still outstanding until a later invocation
+		// sends a terminal response for it.
+		if resp.rescheduler == nil {
+			// without a Rescheduler, nothing will ever re-invoke the
+			// handler, so this request would otherwise hang until
+			// CloudFormation gives up on its own after an hour. Fail
+			// loudly instead of silently stalling the stack.
+			return fmt.Errorf("in-progress response for %q has no Rescheduler attached; call WithRescheduler or send a terminal response instead", resp.LogicalResourceId)
+		}
+		if err := resp.rescheduler.Reschedule(resp.Ctx, resp.req, resp.CallbackContext, resp.CallbackDelaySeconds); err != nil {
+			return fmt.Errorf("could not reschedule callback for %q: %w", resp.LogicalResourceId, err)
+		}
+		*resp.sent = true // indicate to Request.Try() and friends that this invocation is done
+		return nil
+	}
+
 	body, err := json.Marshal(resp)
 	if err != nil {
 		return fmt.Errorf("could not marshal Response: %w", err)
 	}
 	if len(body) > 4096 {
-		return fmt.Errorf("response to %q would include payload of %d bytes, exceeds max 4096", resp.respurl, len(body))
+		if resp.largePayloadUploader == nil {
+			return fmt.Errorf("response to %q would include payload of %d bytes, exceeds max 4096", resp.respurl, len(body))
+		}
+		body, err = resp.offloadDataToS3()
+		if err != nil {
+			return err
+		}
+		if len(body) > 4096 {
+			return fmt.Errorf("response to %q still has payload of %d bytes after offloading Data to s3, exceeds max 4096", resp.respurl, len(body))
+		}
 	}
-	hreq, err := http.NewRequestWithContext(resp.Ctx, "PUT", resp.respurl, bytes.NewReader(body))
+	var lastErr error
+	for attempt := 1; attempt <= retryPolicy.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			if werr := waitForRetry(resp.Ctx, backoffDuration(retryPolicy, attempt)); werr != nil {
+				return fmt.Errorf("http callback to %q canceled while waiting to retry after %w: %w", resp.respurl, lastErr, werr)
+			}
+		}
+		hreq, err := http.NewRequestWithContext(resp.Ctx, "PUT", resp.respurl, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("could not build request object for http callback to %q: %w", resp.respurl, err)
+		}
+		hreq.ContentLength = int64(len(body))
+		result, err := httpClient.Do(hreq)
+		if err != nil {
+			lastErr = fmt.Errorf("http callback to %q failed: %w", resp.respurl, err)
+			continue
+		}
+		// drain before closing so the connection can be reused for the
+		// next attempt (or by whatever httpClient.Do sends next)
+		io.Copy(io.Discard, result.Body)
+		result.Body.Close()
+		if result.StatusCode >= 500 {
+			lastErr = fmt.Errorf("http callback to %q had server error status code %03d", resp.respurl, result.StatusCode)
+			continue
+		}
+		if result.StatusCode < 200 || result.StatusCode >= 299 {
+			// 4xx and other unexpected codes mean the payload itself is
+			// bad, so retrying won't help.
+			return fmt.Errorf("http callback to %q had unexpected http status code %03d", resp.respurl, result.StatusCode)
+		}
+		*resp.sent = true // indicate to Request.Try() and friends that we managed to send a Response
+		return nil
+	}
+	return fmt.Errorf("http callback to %q did not succeed after %d attempts: %w", resp.respurl, retryPolicy.MaxAttempts, lastErr)
+}
+
+// offloadDataToS3 uploads resp.Data via largePayloadUploader, replaces
+// it with an s3DataPointer, and returns the re-marshalled Response.
+func (resp *Response) offloadDataToS3() ([]byte, error) {
+	data, err := json.Marshal(resp.Data)
 	if err != nil {
-		return fmt.Errorf("could not build request object for http callback to %q: %w", resp.respurl, err)
+		return nil, fmt.Errorf("could not marshal Data for s3 offload: %w", err)
 	}
-	hreq.ContentLength = int64(len(body))
-	result, err := http.DefaultClient.Do(hreq)
+	key := fmt.Sprintf("%s%s/%s/%s.json", resp.largePayloadKeyPrefix, resp.StackId, resp.LogicalResourceId, resp.RequestId)
+	versionId, err := resp.largePayloadUploader.PutObject(resp.Ctx, resp.largePayloadBucket, key, data)
 	if err != nil {
-		return fmt.Errorf("http callback to cloudformation at %q failed: %w", resp.respurl, err)
+		return nil, fmt.Errorf("could not upload large payload to s3://%s/%s: %w", resp.largePayloadBucket, key, err)
 	}
-	if result.StatusCode < 200 || result.StatusCode >= 299 {
-		return fmt.Errorf("http callback to %q had unexpected http status code %03d", resp.respurl, result.StatusCode)
+	resp.Data = &s3DataPointer{
+		Bucket:    resp.largePayloadBucket,
+		Key:       key,
+		VersionId: versionId,
 	}
-	*resp.sent = true // indicate to Request.Try() and friends that we managed to send a Response
-	return nil
+	return json.Marshal(resp)
 }
@@ -0,0 +1,110 @@
+package cfncustomresource
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type fakeUploader struct {
+	gotBucket string
+	gotKey    string
+	gotBody   []byte
+	versionId string
+}
+
+func (f *fakeUploader) PutObject(ctx context.Context, bucket, key string, body []byte) (string, error) {
+	f.gotBucket = bucket
+	f.gotKey = key
+	f.gotBody = body
+	return f.versionId, nil
+}
+
+func TestSendOffloadsOversizedDataToS3(t *testing.T) {
+	var receivedBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedBody, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	uploader := &fakeUploader{versionId: "v1"}
+	req := &Request{
+		RequestType:       "Create",
+		ResponseURL:       srv.URL,
+		StackId:           "stack-a",
+		LogicalResourceId: "MyResource",
+		RequestId:         "req-1",
+	}
+
+	type Attrs struct {
+		Blob string
+	}
+	bigAttrs := &Attrs{Blob: strings.Repeat("x", 5000)}
+
+	if err := req.CreatedResponse("phid", bigAttrs).WithLargePayloadBucket("my-bucket", "prefix/", uploader).Send(); err != nil {
+		t.Fatalf("expected Send to succeed via S3 offload, got %v", err)
+	}
+
+	if uploader.gotBucket != "my-bucket" {
+		t.Errorf("expected upload to bucket %q, got %q", "my-bucket", uploader.gotBucket)
+	}
+	const wantKey = "prefix/stack-a/MyResource/req-1.json"
+	if uploader.gotKey != wantKey {
+		t.Errorf("expected key %q, got %q", wantKey, uploader.gotKey)
+	}
+	var uploadedAttrs Attrs
+	if err := json.Unmarshal(uploader.gotBody, &uploadedAttrs); err != nil {
+		t.Fatalf("could not decode uploaded body: %v", err)
+	}
+	if uploadedAttrs != *bigAttrs {
+		t.Errorf("uploaded body = %+v, want %+v", uploadedAttrs, *bigAttrs)
+	}
+
+	if len(receivedBody) > 4096 {
+		t.Fatalf("callback payload still exceeds 4096 bytes after offload (%d bytes)", len(receivedBody))
+	}
+	var received Response
+	if err := json.Unmarshal(receivedBody, &received); err != nil {
+		t.Fatalf("could not decode callback payload: %v", err)
+	}
+	pointer, ok := received.Data.(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected Data to be replaced with an s3 pointer object, got %#v", received.Data)
+	}
+	if pointer["Bucket"] != "my-bucket" || pointer["Key"] != wantKey || pointer["VersionId"] != "v1" {
+		t.Errorf("unexpected s3 pointer %#v", pointer)
+	}
+}
+
+func TestSendWithoutUploaderFailsOverLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("should not have contacted CloudFormation for an oversized payload with no large-payload bucket configured")
+	}))
+	defer srv.Close()
+
+	req := &Request{RequestType: "Create", ResponseURL: srv.URL, StackId: "s", LogicalResourceId: "l"}
+	type Attrs struct{ Blob string }
+	bigAttrs := &Attrs{Blob: strings.Repeat("x", 5000)}
+
+	if err := req.CreatedResponse("phid", bigAttrs).Send(); err == nil {
+		t.Fatal("expected Send to fail when the payload exceeds 4096 bytes with no large-payload bucket configured")
+	}
+}
+
+func TestSendStillTooBigAfterOffloadFails(t *testing.T) {
+	// even after offloading Data, a pointer object with a pathological
+	// VersionId can still exceed the limit; Send must report that
+	// rather than sending a truncated or oversized callback.
+	uploader := &fakeUploader{versionId: strings.Repeat("v", 5000)}
+	req := &Request{RequestType: "Create", StackId: "s", LogicalResourceId: "l"}
+	type Attrs struct{ Blob string }
+	bigAttrs := &Attrs{Blob: strings.Repeat("x", 5000)}
+
+	if err := req.CreatedResponse("phid", bigAttrs).WithLargePayloadBucket("b", "", uploader).Send(); err == nil {
+		t.Fatal("expected Send to fail when the payload is still too big after offloading to s3")
+	}
+}
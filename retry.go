@@ -0,0 +1,106 @@
+package cfncustomresource
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// httpClient is used by Response.Send for its callback to
+// CloudFormation. Override it with SetHTTPClient, for example to set a
+// timeout or a custom Transport.
+var httpClient = http.DefaultClient
+
+// retryPolicy governs how Response.Send retries its callback to
+// CloudFormation. Override it with SetRetryPolicy.
+var retryPolicy = DefaultRetryPolicy
+
+// SetHTTPClient overrides the http.Client used by every subsequent call
+// to Response.Send. This is a package-level setting, since one process
+// generally handles one kind of custom resource and wants one policy
+// for talking back to CloudFormation.
+func SetHTTPClient(c *http.Client) {
+	httpClient = c
+}
+
+// SetRetryPolicy overrides the RetryPolicy used by every subsequent
+// call to Response.Send.
+func SetRetryPolicy(p RetryPolicy) {
+	retryPolicy = p
+}
+
+// A JitterMode selects how backoffDuration randomizes a retry delay.
+type JitterMode int
+
+const (
+	// NoJitter always waits the full computed backoff.
+	NoJitter JitterMode = iota
+	// FullJitter waits a random duration between zero and the full
+	// computed backoff. This is generally the best choice for spreading
+	// out retries from many concurrent callers.
+	FullJitter
+	// EqualJitter waits half the computed backoff, plus a random
+	// duration between zero and the other half. This still spreads out
+	// retries, but guarantees some minimum wait between attempts.
+	EqualJitter
+)
+
+// A RetryPolicy describes how Response.Send retries its callback to
+// CloudFormation after a network error or a 5xx response. 4xx responses
+// are never retried, since they indicate a malformed payload that a
+// retry cannot fix.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of times to attempt the callback,
+	// including the first. A value of 1 disables retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the second attempt. Each
+	// subsequent delay doubles, up to MaxBackoff.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts.
+	MaxBackoff time.Duration
+	// Jitter selects how the computed delay is randomized.
+	Jitter JitterMode
+}
+
+// DefaultRetryPolicy makes up to 5 attempts (the first plus up to 4
+// retries), with exponential backoff
+// starting at 500ms and capped at 30s, with full jitter.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     30 * time.Second,
+	Jitter:         FullJitter,
+}
+
+// backoffDuration returns how long to wait before retry attempt number
+// attempt (the attempt that is about to be made, counting from 2, since
+// there is no wait before the first attempt).
+func backoffDuration(p RetryPolicy, attempt int) time.Duration {
+	d := p.InitialBackoff << uint(attempt-2)
+	if d <= 0 || d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	switch p.Jitter {
+	case FullJitter:
+		d = time.Duration(rand.Int63n(int64(d) + 1))
+	case EqualJitter:
+		half := d / 2
+		d = half + time.Duration(rand.Int63n(int64(half)+1))
+	}
+	return d
+}
+
+// waitForRetry sleeps for d, returning early with ctx.Err() if ctx is
+// canceled first -- so a Lambda-deadline-driven cancellation of
+// Response.Ctx short-circuits the retry loop instead of sleeping it out.
+func waitForRetry(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
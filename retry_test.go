@@ -0,0 +1,100 @@
+package cfncustomresource
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackoffDurationNoJitter(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 30 * time.Second, Jitter: NoJitter}
+	cases := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{2, 1 * time.Second},
+		{3, 2 * time.Second},
+		{4, 4 * time.Second},
+		{5, 8 * time.Second},
+		{6, 16 * time.Second},
+		{7, 30 * time.Second},  // would be 32s, clamped to MaxBackoff
+		{40, 30 * time.Second}, // shift overflows, clamped to MaxBackoff
+	}
+	for _, c := range cases {
+		if got := backoffDuration(p, c.attempt); got != c.want {
+			t.Errorf("backoffDuration(attempt=%d) = %v, want %v", c.attempt, got, c.want)
+		}
+	}
+}
+
+func TestBackoffDurationFullJitter(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 30 * time.Second, Jitter: FullJitter}
+	const base = 8 * time.Second // attempt 5 -> InitialBackoff << 3
+	for i := 0; i < 50; i++ {
+		if d := backoffDuration(p, 5); d < 0 || d > base {
+			t.Fatalf("FullJitter backoff %v out of range [0, %v]", d, base)
+		}
+	}
+}
+
+func TestBackoffDurationEqualJitter(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 30 * time.Second, Jitter: EqualJitter}
+	const base = 8 * time.Second // attempt 5 -> InitialBackoff << 3
+	for i := 0; i < 50; i++ {
+		if d := backoffDuration(p, 5); d < base/2 || d > base {
+			t.Fatalf("EqualJitter backoff %v out of range [%v, %v]", d, base/2, base)
+		}
+	}
+}
+
+// withTestRetryPolicy installs a fast RetryPolicy for the duration of a
+// test and restores whatever was configured before.
+func withTestRetryPolicy(t *testing.T, p RetryPolicy) {
+	t.Helper()
+	orig := retryPolicy
+	SetRetryPolicy(p)
+	t.Cleanup(func() { SetRetryPolicy(orig) })
+}
+
+func TestSendRetriesOn5xxThenSucceeds(t *testing.T) {
+	withTestRetryPolicy(t, RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, Jitter: NoJitter})
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	req := &Request{RequestType: "Create", ResponseURL: srv.URL, StackId: "s", LogicalResourceId: "l"}
+	if err := req.CreatedResponse("phid", nil).Send(); err != nil {
+		t.Fatalf("expected Send to succeed after retrying a 500, got %v", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Fatalf("expected 2 attempts (1 failure then 1 success), got %d", got)
+	}
+}
+
+func TestSendDoesNotRetryOn4xx(t *testing.T) {
+	withTestRetryPolicy(t, RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, Jitter: NoJitter})
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	req := &Request{RequestType: "Create", ResponseURL: srv.URL, StackId: "s", LogicalResourceId: "l"}
+	if err := req.CreatedResponse("phid", nil).Send(); err == nil {
+		t.Fatal("expected Send to fail immediately on a 400, without retrying")
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("expected exactly 1 attempt for a 4xx response, got %d", got)
+	}
+}